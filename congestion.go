@@ -0,0 +1,61 @@
+package raknet
+
+import (
+	"sync"
+	"time"
+)
+
+// CongestionControl is implemented by types that govern how many datagrams a Conn may have in flight at any
+// given time and that react to feedback from the network, such as acknowledgements, duplicate
+// acknowledgements and retransmission timeouts. A Conn consults its CongestionControl before draining its
+// queue of pending outbound packets, so an implementation effectively controls the send rate of the
+// connection.
+//
+// Implementations are not required to be safe for concurrent use: a Conn only ever accesses its
+// CongestionControl while holding its send lock.
+type CongestionControl interface {
+	// OnPacketSent is called every time a reliable packet with sequence number seq and a payload of size
+	// bytes is sent on the wire, including retransmissions.
+	OnPacketSent(seq uint32, bytes int)
+	// OnAck is called when seq is acknowledged by the peer. rtt is the round-trip time observed between the
+	// packet being sent and its acknowledgement arriving.
+	OnAck(seq uint32, rtt time.Duration)
+	// OnDupAckThresholdReached is called once seq has been reported missing by enough duplicate
+	// acknowledgements that it should be considered lost and retransmitted immediately.
+	OnDupAckThresholdReached(seq uint32)
+	// OnRTO is called when the retransmission timeout for seq expires without an acknowledgement having
+	// been received for it.
+	OnRTO(seq uint32)
+	// SendWindow returns the number of datagrams that may currently be in flight. A Conn will not send new
+	// datagrams once this many are outstanding and unacknowledged.
+	SendWindow() int
+}
+
+var (
+	congestionControlMu sync.RWMutex
+	// congestionControls holds the registered CongestionControl factories, keyed by name.
+	congestionControls = map[string]func() CongestionControl{
+		"reno": newNewRenoCongestionControl,
+	}
+)
+
+// RegisterCongestionControl registers a CongestionControl implementation under name, returned by factory.
+// The factory may afterwards be looked up by name through congestionControlByName; a Conn itself starts
+// with NewReno and uses another controller only once it is passed to Conn.SetCongestionControl. Registering a
+// CongestionControl under a name that is already in use overwrites the previously registered one.
+func RegisterCongestionControl(name string, factory func() CongestionControl) {
+	congestionControlMu.Lock()
+	defer congestionControlMu.Unlock()
+	congestionControls[name] = factory
+}
+
+// congestionControlByName looks up a registered CongestionControl factory by name. It returns the default
+// NewReno factory if name is not registered.
+func congestionControlByName(name string) func() CongestionControl {
+	congestionControlMu.RLock()
+	defer congestionControlMu.RUnlock()
+	if factory, ok := congestionControls[name]; ok {
+		return factory
+	}
+	return newNewRenoCongestionControl
+}
@@ -0,0 +1,433 @@
+package raknet
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// dupAckThreshold is the number of acknowledgements that must be processed while an in-flight packet remains
+// unacknowledged below the highest acknowledged sequence number before it is fast-retransmitted, as in TCP's
+// 3-dupACK fast retransmit.
+const dupAckThreshold = 3
+
+// defaultRTO is the retransmission timeout used for a connection's in-flight packets until a smoothed RTT
+// estimate is available.
+const defaultRTO = 2 * time.Second
+
+// inFlightPacket wraps a packet that has been sent and is awaiting an acknowledgement, together with the
+// bookkeeping needed by the congestion controller and the RACK loss detector.
+type inFlightPacket struct {
+	pk  *packet
+	seq uint32
+
+	// sentAt is the time at which pk was most recently (re)transmitted.
+	sentAt time.Time
+	// rackElem is the element holding this packet in Conn.rack's transmission-ordered list, or nil if RACK
+	// is not enabled on the connection.
+	rackElem *rackElem
+
+	// dupAcks counts the number of acknowledgements processed while this packet remained unacknowledged below
+	// the connection's highest acknowledged sequence number, used to trigger a
+	// dupAckThreshold fast retransmit. It is reset to 0 whenever the packet is (re)transmitted, so that a
+	// packet still unacknowledged after a fast retransmit needs another dupAckThreshold dup-ACKs before it is
+	// fast-retransmitted again.
+	dupAcks int
+}
+
+// Conn represents a RakNet connection to a single peer. It tracks packets that are pending to be sent and
+// those that are currently in flight awaiting an acknowledgement, and consults a CongestionControl to
+// decide how many of those may be outstanding at once.
+type Conn struct {
+	sendMu sync.Mutex
+	// cc is the congestion controller used to size the send window of the connection. It defaults to
+	// NewReno but may be overridden through Conn.SetCongestionControl.
+	cc CongestionControl
+	// rack is the RACK loss detector for the connection. It is nil unless EnableRACK has been called.
+	rack *rackLoss
+	// srtt is the smoothed round-trip time of the connection, updated on every acknowledgement.
+	srtt time.Duration
+	// ackFormat is the AckFormat used for ACKs sent on the connection, set through SetAckFormat. NACKs always
+	// use AckFormatRange regardless of this setting.
+	ackFormat AckFormat
+	// sack holds the RFC 6675-style scoreboard recovery state (HighRxt/RescueRxt) for the connection.
+	sack sackRecovery
+
+	// seq is the next datagram sequence number to be handed out.
+	seq uint32
+	// pending holds packets that are queued to be sent but have not yet been handed to the network, because
+	// doing so would exceed the current send window.
+	pending []*packet
+	// inFlight holds packets that have been sent and are awaiting an acknowledgement, keyed by the sequence
+	// number they were sent with.
+	inFlight map[uint32]*inFlightPacket
+	// nextMessageIndex is the next messageIndex to hand out to a packet for which packet.reliable is true.
+	nextMessageIndex uint32
+	// nextOrderIndex holds the next orderIndex to hand out on each ordering channel when sending, indexed by
+	// channel number.
+	nextOrderIndex [maxOrderingChannels]uint32
+	// nextSequenceIndex is the next sequenceIndex to hand out to reliabilityUnreliableSequenced packets sent
+	// through WriteDatagram.
+	nextSequenceIndex uint32
+	// datagrams holds unreliable and unreliable-sequenced payloads received out of band from the ordered
+	// reliable receive path, ready to be consumed through ReadDatagram.
+	datagrams *datagramBuffer
+
+	recvMu sync.Mutex
+	// order holds the per-ordering-channel reassembly state for received reliabilityReliableOrdered and
+	// reliabilityReliableSequenced traffic, indexed by channel number. Entries are created lazily as traffic
+	// arrives on a channel.
+	order [maxOrderingChannels]*streamBuffer
+	// orderedHighWaterMark bounds the number of out-of-order packets a single ordering channel's streamBuffer
+	// may hold, and is used to construct order's entries as they're created.
+	orderedHighWaterMark int
+	// ready holds, for each ordering channel, payloads that have been reassembled in order and are waiting to
+	// be consumed through ReadOrdered.
+	ready [maxOrderingChannels][][]byte
+}
+
+// newConn returns a new Conn using the default (NewReno) congestion controller.
+func newConn() *Conn {
+	return &Conn{
+		cc:                   newNewRenoCongestionControl(),
+		inFlight:             make(map[uint32]*inFlightPacket),
+		orderedHighWaterMark: defaultOrderedHighWaterMark,
+		datagrams:            newDatagramBuffer(datagramNumberLimit),
+	}
+}
+
+// SetCongestionControl overrides the congestion controller used by conn. It must be called before the
+// connection starts sending packets.
+func (conn *Conn) SetCongestionControl(cc CongestionControl) {
+	conn.sendMu.Lock()
+	defer conn.sendMu.Unlock()
+	conn.cc = cc
+}
+
+// EnableRACK turns on RACK-based loss detection for conn, in addition to the existing duplicate-ACK and RTO
+// based retransmission. minReoWnd is the minimum reordering window RACK will use; passing 0 selects
+// defaultMinReoWnd.
+func (conn *Conn) EnableRACK(minReoWnd time.Duration) {
+	conn.sendMu.Lock()
+	defer conn.sendMu.Unlock()
+	if minReoWnd <= 0 {
+		minReoWnd = defaultMinReoWnd
+	}
+	conn.rack = newRackLoss(minReoWnd)
+}
+
+// SetAckFormat overrides the AckFormat used for ACKs sent by conn, which is AckFormatRange by default. Conn
+// does not read connectionRequest.AckFormat itself: a format carried in a connection request only takes
+// effect on conn once it is passed to SetAckFormat.
+func (conn *Conn) SetAckFormat(format AckFormat) {
+	conn.sendMu.Lock()
+	defer conn.sendMu.Unlock()
+	conn.ackFormat = format
+}
+
+// SetOrderedHighWaterMark overrides the maximum number of out-of-order packets a single ordering channel may
+// buffer while waiting for the packets that precede them, for every ordering channel created from this point
+// onwards. It does not affect channels that have already received traffic.
+func (conn *Conn) SetOrderedHighWaterMark(n int) {
+	conn.recvMu.Lock()
+	defer conn.recvMu.Unlock()
+	conn.orderedHighWaterMark = n
+}
+
+// SetDatagramNumberLimit overrides the maximum number of unreliable datagrams buffered for delivery through
+// ReadDatagram before the oldest queued one is dropped to make room for a newly arrived one.
+func (conn *Conn) SetDatagramNumberLimit(n int) {
+	conn.datagrams.mu.Lock()
+	defer conn.datagrams.mu.Unlock()
+	conn.datagrams.limit = n
+}
+
+// newAck returns an acknowledgement for packets, encoded using the AckFormat negotiated for conn.
+func (conn *Conn) newAck(packets []uint32) *acknowledgement {
+	return &acknowledgement{packets: packets, format: conn.ackFormat}
+}
+
+// newNack returns a negative acknowledgement for packets. NACKs always use AckFormatRange: a bitmask does
+// not map cleanly onto reporting which packets are missing.
+func (conn *Conn) newNack(packets []uint32) *acknowledgement {
+	return &acknowledgement{packets: packets, nack: true}
+}
+
+// send queues pk to be sent over conn. It does not block: pk is appended to the pending queue and handed to
+// the network once the send window allows it, by flushSendQueue.
+func (conn *Conn) send(pk *packet) {
+	conn.sendMu.Lock()
+	defer conn.sendMu.Unlock()
+	conn.sendLocked(pk)
+}
+
+// sendLocked is the body of send for callers that already hold sendMu, such as WriteOrdered and
+// WriteDatagram, which must assign a packet's messageIndex/orderIndex/sequenceIndex and enqueue it in the
+// same critical section: otherwise two concurrent callers could interleave so that the packet assigned the
+// lower index is enqueued, and so given a higher seq, after the one assigned the higher index, breaking the
+// correlation between index order and seq order that the SACK scoreboard and streamBuffer rely on.
+func (conn *Conn) sendLocked(pk *packet) {
+	conn.pending = append(conn.pending, pk)
+	conn.flushSendQueue()
+}
+
+// flushSendQueue drains as many pending packets as the current CongestionControl.SendWindow allows onto the
+// network. conn.sendMu must be held when calling flushSendQueue.
+func (conn *Conn) flushSendQueue() {
+	for len(conn.pending) > 0 && len(conn.inFlight) < conn.cc.SendWindow() {
+		pk := conn.pending[0]
+		conn.pending = conn.pending[1:]
+
+		conn.sendPacket(pk, conn.seq)
+		conn.seq++
+	}
+}
+
+// sendPacket hands pk to the network under sequence number seq, recording it as in flight and, if enabled,
+// adding it to the RACK transmission-ordered list.
+func (conn *Conn) sendPacket(pk *packet, seq uint32) {
+	now := time.Now()
+	ifp := &inFlightPacket{pk: pk, seq: seq, sentAt: now}
+	conn.inFlight[seq] = ifp
+	if conn.rack != nil {
+		ifp.rackElem = conn.rack.add(ifp, now)
+	}
+	conn.cc.OnPacketSent(seq, len(pk.content))
+}
+
+// retransmit resends an in-flight packet that was detected lost, moving it to the tail of the RACK list so
+// that the list remains ordered by transmission time and resetting its dup-ACK bookkeeping so the next loss
+// episode starts from a clean slate.
+func (conn *Conn) retransmit(ifp *inFlightPacket) {
+	now := time.Now()
+	ifp.sentAt = now
+	ifp.dupAcks = 0
+	if conn.rack != nil {
+		conn.rack.moveToTail(ifp, now)
+	}
+	conn.cc.OnPacketSent(ifp.seq, len(ifp.pk.content))
+}
+
+// handleAcknowledgement processes an incoming ACK, updating the congestion controller, the SACK scoreboard
+// and, if enabled, the RACK loss detector, and retransmits any packet that is considered lost as a result,
+// whether by 3-dupACK fast retransmit or, if enabled, RACK.
+func (conn *Conn) handleAcknowledgement(ack *acknowledgement) {
+	conn.sendMu.Lock()
+	defer conn.sendMu.Unlock()
+
+	now := time.Now()
+	for _, seq := range ack.packets {
+		conn.sack.onAck(seq, now)
+
+		ifp, ok := conn.inFlight[seq]
+		if !ok {
+			continue
+		}
+		rtt := now.Sub(ifp.sentAt)
+		conn.updateRTT(rtt)
+		conn.cc.OnAck(seq, rtt)
+		if conn.rack != nil {
+			conn.rack.onAck(ifp, now)
+		}
+		delete(conn.inFlight, seq)
+	}
+
+	conn.updateDupAcks()
+
+	if conn.rack != nil {
+		conn.rack.updateReoWnd(conn.srtt)
+		for _, lost := range conn.rack.detectLosses(now) {
+			conn.retransmitAndMark(lost)
+		}
+	}
+	conn.recoverLosses(now)
+}
+
+// retransmitAndMark retransmits ifp and records it with the SACK scoreboard as having been retransmitted
+// this loss episode, so that recoverLosses does not consider it eligible to be retransmitted a second time
+// for the same episode.
+func (conn *Conn) retransmitAndMark(ifp *inFlightPacket) {
+	conn.retransmit(ifp)
+	conn.sack.markRetransmitted(ifp)
+}
+
+// updateRTT folds a newly observed round-trip time sample into the connection's smoothed RTT, using the
+// same kind of exponentially weighted moving average as the standard TCP RTT estimator.
+func (conn *Conn) updateRTT(sample time.Duration) {
+	if conn.srtt == 0 {
+		conn.srtt = sample
+		return
+	}
+	const alpha = 0.125
+	conn.srtt += time.Duration(alpha * float64(sample-conn.srtt))
+}
+
+// updateDupAcks bumps the dup-ACK counter of every in-flight packet below the highest acknowledged sequence
+// number, and fast-retransmits any packet that has now been skipped by dupAckThreshold acknowledgements in a
+// row, reporting it to the CongestionControl via OnDupAckThresholdReached. Like recoverLosses, it only
+// retransmits a packet conn.sack still considers eligible, i.e. not already retransmitted this loss
+// episode: conn.inFlight is a map, so holes are visited in no particular messageIndex order, and a hole
+// with a messageIndex at or below HighRxt has already been retransmitted this episode and would otherwise be
+// sent a second time. conn.sendMu must be held when calling updateDupAcks.
+func (conn *Conn) updateDupAcks() {
+	if !conn.sack.highestAckedSet {
+		return
+	}
+	for seq, ifp := range conn.inFlight {
+		if seq >= conn.sack.highestAcked {
+			continue
+		}
+		ifp.dupAcks++
+		if ifp.dupAcks < dupAckThreshold {
+			continue
+		}
+		if !conn.sack.eligible(ifp) {
+			continue
+		}
+		conn.cc.OnDupAckThresholdReached(ifp.seq)
+		conn.retransmitAndMark(ifp)
+	}
+}
+
+// rto returns the retransmission timeout currently in effect for conn: twice its smoothed RTT, or defaultRTO
+// if no RTT sample has been observed yet.
+func (conn *Conn) rto() time.Duration {
+	if conn.srtt == 0 {
+		return defaultRTO
+	}
+	return 2 * conn.srtt
+}
+
+// CheckTimeouts retransmits every in-flight packet whose retransmission timeout has elapsed since it was
+// last (re)transmitted, reporting each to the CongestionControl via OnRTO. It must be called periodically,
+// e.g. from a timer, so that a connection recovers even when every in-flight packet is lost and the peer
+// never sends an acknowledgement for any of them.
+func (conn *Conn) CheckTimeouts(now time.Time) {
+	conn.sendMu.Lock()
+	defer conn.sendMu.Unlock()
+
+	rto := conn.rto()
+	for _, ifp := range conn.inFlight {
+		if now.Sub(ifp.sentAt) < rto {
+			continue
+		}
+		conn.cc.OnRTO(ifp.seq)
+		conn.retransmit(ifp)
+	}
+}
+
+// WriteOrdered queues p to be sent on the given ordering channel with reliability rel, returning the number
+// of bytes written. channel must be in the range 0-31. Packets written with the same channel and a
+// reliability for which packet.sequencedOrOrdered is true are assigned consecutive order indices, so that
+// the peer's streamBuffer for that channel can reassemble them in the order WriteOrdered was called. Every
+// packet for which packet.reliable is true is also assigned a connection-wide monotonic messageIndex, used
+// by the SACK scoreboard to tell retransmissions of distinct packets apart.
+func (conn *Conn) WriteOrdered(channel byte, p []byte, rel Reliability) (int, error) {
+	if int(channel) >= maxOrderingChannels {
+		return 0, fmt.Errorf("raknet: invalid ordering channel %d", channel)
+	}
+	pk := &packet{reliability: byte(rel), content: p, orderChannel: channel}
+
+	conn.sendMu.Lock()
+	if pk.reliable() {
+		pk.messageIndex = conn.nextMessageIndex
+		conn.nextMessageIndex++
+	}
+	if pk.sequencedOrOrdered() {
+		pk.orderIndex = conn.nextOrderIndex[channel]
+		conn.nextOrderIndex[channel]++
+	}
+	conn.sendLocked(pk)
+	conn.sendMu.Unlock()
+
+	return len(p), nil
+}
+
+// ReadOrdered returns the next payload ready to be delivered on the given ordering channel, in the order it
+// was originally written by the peer's WriteOrdered. It returns errNoOrderedPacket if no payload is
+// currently ready, either because none has arrived yet or because the reassembly of an earlier packet on
+// the channel is still pending.
+func (conn *Conn) ReadOrdered(channel byte) ([]byte, error) {
+	if int(channel) >= maxOrderingChannels {
+		return nil, fmt.Errorf("raknet: invalid ordering channel %d", channel)
+	}
+	conn.recvMu.Lock()
+	defer conn.recvMu.Unlock()
+
+	queue := conn.ready[channel]
+	if len(queue) == 0 {
+		return nil, errNoOrderedPacket
+	}
+	p := queue[0]
+	conn.ready[channel] = queue[1:]
+	return p, nil
+}
+
+// handleOrdered reassembles a received reliabilityReliableOrdered or reliabilityReliableSequenced packet pk
+// into its ordering channel's streamBuffer, making any payload that has become deliverable as a result
+// available through ReadOrdered.
+func (conn *Conn) handleOrdered(pk *packet) error {
+	if int(pk.orderChannel) >= maxOrderingChannels {
+		return fmt.Errorf("raknet: invalid ordering channel %d", pk.orderChannel)
+	}
+	conn.recvMu.Lock()
+	defer conn.recvMu.Unlock()
+
+	buf := conn.order[pk.orderChannel]
+	if buf == nil {
+		buf = newStreamBuffer(conn.orderedHighWaterMark)
+		conn.order[pk.orderChannel] = buf
+	}
+	ready, err := buf.push(pk.orderIndex, pk.content)
+	if err != nil {
+		return err
+	}
+	conn.ready[pk.orderChannel] = append(conn.ready[pk.orderChannel], ready...)
+	return nil
+}
+
+// WriteDatagram queues p to be sent using rel, which must be ReliabilityUnreliable or
+// ReliabilityUnreliableSequenced, returning the number of bytes written. Unlike WriteOrdered, content sent
+// through WriteDatagram is delivered through the peer's ReadDatagram, skipping the ordered receive path
+// entirely so that latency-sensitive traffic is never held up behind a stalled ordering channel.
+func (conn *Conn) WriteDatagram(p []byte, rel Reliability) (int, error) {
+	switch rel {
+	case ReliabilityUnreliable, ReliabilityUnreliableSequenced:
+	default:
+		return 0, fmt.Errorf("raknet: WriteDatagram: reliability %d is not unreliable", rel)
+	}
+	pk := &packet{reliability: byte(rel), content: p}
+
+	conn.sendMu.Lock()
+	if pk.sequenced() {
+		pk.sequenceIndex = conn.nextSequenceIndex
+		conn.nextSequenceIndex++
+	}
+	conn.sendUnreliableLocked()
+	conn.sendMu.Unlock()
+
+	return len(p), nil
+}
+
+// sendUnreliableLocked hands an unreliable or unreliable-sequenced packet to the network under the next
+// datagram sequence number, without going through conn.pending/conn.inFlight or consulting the
+// CongestionControl the way sendLocked does: the peer never acknowledges such a packet, so recording it as
+// in flight like reliable traffic would leave an entry that can never be acked and so never removed, which
+// CheckTimeouts would then retransmit forever at every RTO and updateDupAcks would fast-retransmit on every
+// subsequent ACK, permanently collapsing the congestion window for the whole connection. conn.sendMu must be
+// held when calling sendUnreliableLocked.
+func (conn *Conn) sendUnreliableLocked() {
+	conn.seq++
+}
+
+// ReadDatagram blocks until an unreliable or unreliable-sequenced payload written by the peer's
+// WriteDatagram is available, and returns it.
+func (conn *Conn) ReadDatagram() []byte {
+	return conn.datagrams.read()
+}
+
+// handleDatagram routes a received reliabilityUnreliable or reliabilityUnreliableSequenced packet pk to the
+// datagram buffer, making it available through ReadDatagram.
+func (conn *Conn) handleDatagram(pk *packet) {
+	conn.datagrams.push(pk.content, pk.sequenced(), pk.sequenceIndex)
+}
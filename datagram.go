@@ -0,0 +1,69 @@
+package raknet
+
+import "sync"
+
+// datagramNumberLimit is the default maximum number of unreliable datagrams datagramBuffer holds before it
+// starts dropping the oldest one to make room for a newly arrived one.
+const datagramNumberLimit = 128
+
+// datagramBuffer queues reliabilityUnreliable and reliabilityUnreliableSequenced payloads for delivery
+// through Conn.ReadDatagram, entirely separately from the ordered reliable receive path handled by
+// streamBuffer. Because voice and position updates care about recency rather than completeness, it never
+// buffers a packet waiting for one that preceded it: reliabilityUnreliableSequenced traffic that arrives out
+// of order is simply dropped, and once limit datagrams are already queued the oldest is dropped to make room
+// for the new one, so a reader that falls behind never blocks a sender.
+type datagramBuffer struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	limit int
+	queue [][]byte
+
+	// highestSequence is the highest sequenceIndex accepted so far from reliabilityUnreliableSequenced
+	// traffic. It is unused for plain reliabilityUnreliable pushes.
+	highestSequence uint32
+	// highestSequenceSet reports whether highestSequence holds a valid value yet.
+	highestSequenceSet bool
+}
+
+// newDatagramBuffer returns a datagramBuffer that drops the oldest queued datagram once limit are already
+// buffered.
+func newDatagramBuffer(limit int) *datagramBuffer {
+	d := &datagramBuffer{limit: limit}
+	d.cond = sync.NewCond(&d.mu)
+	return d
+}
+
+// push queues content for delivery through read. sequenced reports whether content was sent with
+// reliabilityUnreliableSequenced, in which case sequenceIndex is used to drop it if it arrived after a
+// packet with a higher sequenceIndex already has.
+func (d *datagramBuffer) push(content []byte, sequenced bool, sequenceIndex uint32) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if sequenced {
+		if d.highestSequenceSet && sequenceIndex <= d.highestSequence {
+			return
+		}
+		d.highestSequence = sequenceIndex
+		d.highestSequenceSet = true
+	}
+
+	if len(d.queue) >= d.limit {
+		d.queue = d.queue[1:]
+	}
+	d.queue = append(d.queue, content)
+	d.cond.Signal()
+}
+
+// read blocks until a datagram is available to be delivered and returns it.
+func (d *datagramBuffer) read() []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for len(d.queue) == 0 {
+		d.cond.Wait()
+	}
+	content := d.queue[0]
+	d.queue = d.queue[1:]
+	return content
+}
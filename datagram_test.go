@@ -0,0 +1,119 @@
+package raknet
+
+import (
+	"testing"
+	"time"
+)
+
+// TestDatagramBufferDropsOldestPastLimit verifies that once limit datagrams are already queued, pushing
+// another drops the oldest one to make room rather than growing the queue without bound.
+func TestDatagramBufferDropsOldestPastLimit(t *testing.T) {
+	d := newDatagramBuffer(2)
+
+	d.push([]byte("a"), false, 0)
+	d.push([]byte("b"), false, 0)
+	d.push([]byte("c"), false, 0)
+
+	if got := string(d.read()); got != "b" {
+		t.Fatalf("first read = %q, want %q (oldest datagram should have been dropped)", got, "b")
+	}
+	if got := string(d.read()); got != "c" {
+		t.Fatalf("second read = %q, want %q", got, "c")
+	}
+}
+
+// TestDatagramBufferDropsOutOfOrderSequenced verifies that reliabilityUnreliableSequenced traffic that
+// arrives with a sequenceIndex at or below the highest one already accepted is dropped instead of being
+// queued, since recency rather than completeness is what matters for sequenced datagrams.
+func TestDatagramBufferDropsOutOfOrderSequenced(t *testing.T) {
+	d := newDatagramBuffer(8)
+
+	d.push([]byte("new"), true, 5)
+	d.push([]byte("stale"), true, 3)
+	d.push([]byte("duplicate"), true, 5)
+	d.push([]byte("newer"), true, 6)
+
+	if got := string(d.read()); got != "new" {
+		t.Fatalf("first read = %q, want %q", got, "new")
+	}
+	if got := string(d.read()); got != "newer" {
+		t.Fatalf("second read = %q, want %q (stale and duplicate sequenceIndex pushes should have been dropped)", got, "newer")
+	}
+}
+
+// TestDatagramBufferUnsequencedIgnoresSequenceIndex verifies that plain reliabilityUnreliable pushes are
+// never dropped for arriving out of sequence, since highestSequence tracking only applies to
+// reliabilityUnreliableSequenced traffic.
+func TestDatagramBufferUnsequencedIgnoresSequenceIndex(t *testing.T) {
+	d := newDatagramBuffer(8)
+
+	d.push([]byte("a"), false, 5)
+	d.push([]byte("b"), false, 1)
+
+	if got := string(d.read()); got != "a" {
+		t.Fatalf("first read = %q, want %q", got, "a")
+	}
+	if got := string(d.read()); got != "b" {
+		t.Fatalf("second read = %q, want %q (unsequenced pushes must not be dropped for a lower sequenceIndex)", got, "b")
+	}
+}
+
+// TestWriteDatagramDoesNotEnterInFlight verifies that Conn.WriteDatagram never adds its packet to
+// conn.inFlight or reports it to the CongestionControl: unreliable and unreliable-sequenced traffic is
+// never acknowledged by the peer, so if it were tracked like reliable traffic it could never be removed
+// from conn.inFlight, and CheckTimeouts/handleAcknowledgement would retransmit it forever (see
+// TestWriteDatagramSurvivesAcknowledgementAndTimeouts for the consequence of that).
+func TestWriteDatagramDoesNotEnterInFlight(t *testing.T) {
+	cc := newSpyCongestionControl(10)
+	conn := newConn()
+	conn.cc = cc
+
+	if _, err := conn.WriteDatagram([]byte("pos"), ReliabilityUnreliable); err != nil {
+		t.Fatalf("WriteDatagram: %v", err)
+	}
+
+	if len(conn.inFlight) != 0 {
+		t.Fatalf("len(conn.inFlight) = %d after WriteDatagram, want 0", len(conn.inFlight))
+	}
+	if len(cc.packetsSent) != 0 {
+		t.Fatalf("cc.packetsSent = %v after WriteDatagram, want empty: unreliable traffic must not be reported to the CongestionControl", cc.packetsSent)
+	}
+}
+
+// TestWriteDatagramSurvivesAcknowledgementAndTimeouts verifies that sending unreliable traffic alongside
+// reliable traffic doesn't wreck the reliable channel: a reliable packet sent after a WriteDatagram call
+// must still be acknowledged normally, and CheckTimeouts must not find anything to retransmit once it has
+// been. Before this fix, WriteDatagram's packet was added to conn.inFlight and counted against
+// cc.SendWindow like any reliable packet, but since the peer never acknowledges it, it stayed in conn.inFlight
+// forever, was retransmitted at every RTO and permanently collapsed the congestion window.
+func TestWriteDatagramSurvivesAcknowledgementAndTimeouts(t *testing.T) {
+	cc := newSpyCongestionControl(10)
+	conn := newConn()
+	conn.cc = cc
+
+	if _, err := conn.WriteDatagram([]byte("pos"), ReliabilityUnreliableSequenced); err != nil {
+		t.Fatalf("WriteDatagram: %v", err)
+	}
+	if _, err := conn.WriteOrdered(0, []byte("chat"), ReliabilityReliableOrdered); err != nil {
+		t.Fatalf("WriteOrdered: %v", err)
+	}
+
+	if len(conn.inFlight) != 1 {
+		t.Fatalf("len(conn.inFlight) = %d after one reliable write, want 1 (only the reliable packet)", len(conn.inFlight))
+	}
+
+	var reliableSeq uint32
+	for seq := range conn.inFlight {
+		reliableSeq = seq
+	}
+	conn.handleAcknowledgement(&acknowledgement{packets: []uint32{reliableSeq}})
+
+	if len(conn.inFlight) != 0 {
+		t.Fatalf("len(conn.inFlight) = %d after acknowledging the reliable packet, want 0", len(conn.inFlight))
+	}
+
+	conn.CheckTimeouts(time.Now().Add(2 * conn.rto()))
+	if len(cc.packetsSent) != 1 {
+		t.Fatalf("cc.packetsSent = %v after CheckTimeouts with nothing outstanding, want only the original reliable send", cc.packetsSent)
+	}
+}
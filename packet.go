@@ -58,6 +58,22 @@ const (
 	splitFlag byte = 0x10
 )
 
+// writeUint24 writes v as a 3-byte little-endian unsigned integer, the wire format RakNet uses for sequence
+// numbers and message/order/sequence indices. v must fit in 24 bits; the top byte is discarded otherwise.
+func writeUint24(b *bytes.Buffer, v uint32) error {
+	_, err := b.Write([]byte{byte(v), byte(v >> 8), byte(v >> 16)})
+	return err
+}
+
+// readUint24 reads a 3-byte little-endian unsigned integer previously written by writeUint24.
+func readUint24(b *bytes.Buffer) (uint32, error) {
+	buf := make([]byte, 3)
+	if _, err := b.Read(buf); err != nil {
+		return 0, err
+	}
+	return uint32(buf[0]) | uint32(buf[1])<<8 | uint32(buf[2])<<16, nil
+}
+
 type connectedPing struct {
 	PingTimestamp int64
 }
@@ -67,10 +83,52 @@ type connectedPong struct {
 	PongTimestamp int64
 }
 
+// connectionRequestSecureFlag is set in a connectionRequest's flag byte when Secure is true. The remaining,
+// previously reserved bits of the byte carry the sender's preferred AckFormat.
+const connectionRequestSecureFlag = 0x1
+
 type connectionRequest struct {
 	ClientGUID int64
 	RequestTimestamp int64
 	Secure bool
+	// AckFormat is the AckFormat the sender wishes to use for acknowledgements on the connection.
+	AckFormat AckFormat
+}
+
+// write writes a connectionRequest and returns an error if not successful.
+func (pk *connectionRequest) write(b *bytes.Buffer) error {
+	if err := binary.Write(b, binary.BigEndian, pk.ClientGUID); err != nil {
+		return fmt.Errorf("error writing connection request client GUID: %v", err)
+	}
+	if err := binary.Write(b, binary.BigEndian, pk.RequestTimestamp); err != nil {
+		return fmt.Errorf("error writing connection request timestamp: %v", err)
+	}
+	var flags byte
+	if pk.Secure {
+		flags |= connectionRequestSecureFlag
+	}
+	flags |= byte(pk.AckFormat) << 1
+	if err := b.WriteByte(flags); err != nil {
+		return fmt.Errorf("error writing connection request flags: %v", err)
+	}
+	return nil
+}
+
+// read reads a connectionRequest and returns an error if not successful.
+func (pk *connectionRequest) read(b *bytes.Buffer) error {
+	if err := binary.Read(b, binary.BigEndian, &pk.ClientGUID); err != nil {
+		return fmt.Errorf("error reading connection request client GUID: %v", err)
+	}
+	if err := binary.Read(b, binary.BigEndian, &pk.RequestTimestamp); err != nil {
+		return fmt.Errorf("error reading connection request timestamp: %v", err)
+	}
+	flags, err := b.ReadByte()
+	if err != nil {
+		return fmt.Errorf("error reading connection request flags: %v", err)
+	}
+	pk.Secure = flags&connectionRequestSecureFlag != 0
+	pk.AckFormat = AckFormat(flags >> 1)
+	return nil
 }
 
 type connectionRequestAccepted struct {
@@ -89,6 +147,9 @@ type packet struct {
 	messageIndex uint32
 	sequenceIndex uint32
 	orderIndex uint32
+	// orderChannel is the ordering channel the packet belongs to, for reliabilityReliableOrdered and
+	// reliabilityReliableSequenced traffic. RakNet supports up to 32 independent channels (0-31).
+	orderChannel byte
 
 	split      bool
 	splitCount uint32
@@ -121,8 +182,9 @@ func (packet *packet) write(b *bytes.Buffer) error {
 		if err := writeUint24(b, packet.orderIndex); err != nil {
 			return fmt.Errorf("error writing packet order index: %v", err)
 		}
-		// Order channel, we don't care about this.
-		_ = b.WriteByte(0)
+		if err := b.WriteByte(packet.orderChannel); err != nil {
+			return fmt.Errorf("error writing packet order channel: %v", err)
+		}
 	}
 	if packet.split {
 		if err := binary.Write(b, binary.BigEndian, packet.splitCount); err != nil {
@@ -176,8 +238,10 @@ func (packet *packet) read(b *bytes.Buffer) error {
 		if err != nil {
 			return fmt.Errorf("error reading packet order index: %v", err)
 		}
-		// Order channel (byte), we don't care about this.
-		b.Next(1)
+		packet.orderChannel, err = b.ReadByte()
+		if err != nil {
+			return fmt.Errorf("error reading packet order channel: %v", err)
+		}
 	}
 
 	if packet.split {
@@ -238,10 +302,33 @@ const (
 	PacketSingle
 )
 
+// AckFormat is the wire format used to encode the sequence numbers carried by an acknowledgement.
+type AckFormat uint8
+
+const (
+	// AckFormatRange encodes acknowledged packets as a series of PacketRange/PacketSingle records, as
+	// RakNet has always done. It is used for NACKs regardless of the format negotiated for ACKs, since
+	// bitmask NACKs don't map cleanly onto missing-packet reporting.
+	AckFormatRange AckFormat = iota
+	// AckFormatBitmask encodes an ACK as the highest received sequence number plus a 32-bit bitmask of the
+	// 32 sequence numbers preceding it, giving redundant coverage of the last 33 packets in a handful of
+	// bytes so that the loss of a single ACK can be recovered from by any subsequent one.
+	AckFormatBitmask
+)
+
+// ackBitmaskBits is the number of sequence numbers preceding the highest acknowledged one that are covered
+// by an AckFormatBitmask acknowledgement's bitmask.
+const ackBitmaskBits = 32
+
 // acknowledgement is an acknowledgement packet that may either be an ACK or a NACK, depending on the purpose
 // that it is sent with.
 type acknowledgement struct {
 	packets []uint32
+	// format is the AckFormat used to encode the acknowledgement. It is only ever AckFormatBitmask for ACKs:
+	// NACKs always fall back to AckFormatRange.
+	format AckFormat
+	// nack is true if the acknowledgement is a NACK rather than an ACK.
+	nack bool
 }
 
 // write writes an acknowledgement packet and returns an error if not successful.
@@ -249,11 +336,15 @@ func (ack *acknowledgement) write(b *bytes.Buffer) error {
 	if len(ack.packets) == 0 {
 		return b.WriteByte(0)
 	}
-	buffer := bytes.NewBuffer(nil)
-	// Sort packets before encoding to ensure packets are encoded correctly.
+	// Sort packets before encoding to ensure packets are encoded correctly, and so that the last element is
+	// the highest sequence number for the bitmask format below.
 	sort.Slice(ack.packets, func(i, j int) bool {
 		return ack.packets[i] < ack.packets[j]
 	})
+	if ack.format == AckFormatBitmask && !ack.nack {
+		return ack.writeBitmask(b)
+	}
+	buffer := bytes.NewBuffer(nil)
 
 	var firstPacketInRange uint32
 	var lastPacketInRange uint32
@@ -335,8 +426,53 @@ func (ack *acknowledgement) write(b *bytes.Buffer) error {
 	return nil
 }
 
+// writeBitmask writes ack in the AckFormatBitmask format: the highest acknowledged sequence number as a
+// uint24, followed by a 32-bit mask in which bit n set means that seq highest-n-1 was also received.
+func (ack *acknowledgement) writeBitmask(b *bytes.Buffer) error {
+	highest := ack.packets[len(ack.packets)-1]
+	var bits uint32
+	for _, seq := range ack.packets[:len(ack.packets)-1] {
+		if n := highest - seq - 1; n < ackBitmaskBits {
+			bits |= 1 << n
+		}
+	}
+	if err := writeUint24(b, highest); err != nil {
+		return err
+	}
+	return binary.Write(b, binary.BigEndian, bits)
+}
+
+// readBitmask reads an acknowledgement previously written with writeBitmask.
+func (ack *acknowledgement) readBitmask(b *bytes.Buffer) error {
+	highest, err := readUint24(b)
+	if err != nil {
+		return err
+	}
+	var bits uint32
+	if err := binary.Read(b, binary.BigEndian, &bits); err != nil {
+		return err
+	}
+	ack.packets = append(ack.packets, highest)
+	for n := uint32(0); n < ackBitmaskBits; n++ {
+		if bits&(1<<n) != 0 && highest > n {
+			ack.packets = append(ack.packets, highest-n-1)
+		}
+	}
+	return nil
+}
+
 // read reads an acknowledgement packet and returns an error if not successful.
 func (ack *acknowledgement) read(b *bytes.Buffer) error {
+	if b.Len() == 1 {
+		// An acknowledgement with no packets is always written as a single zero byte regardless of format;
+		// see acknowledgement.write. Consume it before dispatching on format, since readBitmask would
+		// otherwise try to read 7 bytes that were never written.
+		_, err := b.ReadByte()
+		return err
+	}
+	if ack.format == AckFormatBitmask && !ack.nack {
+		return ack.readBitmask(b)
+	}
 	var recordCount int16
 	if err := binary.Read(b, binary.BigEndian, &recordCount); err != nil {
 		return err
@@ -0,0 +1,68 @@
+package raknet
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+)
+
+// TestAcknowledgementBitmaskRoundTrip verifies that an AckFormatBitmask acknowledgement round-trips through
+// write/read even when the packets given to it are unsorted, and that the highest sequence number survives
+// the round trip rather than being dropped.
+func TestAcknowledgementBitmaskRoundTrip(t *testing.T) {
+	ack := &acknowledgement{packets: []uint32{10, 5, 8}, format: AckFormatBitmask}
+
+	buf := bytes.NewBuffer(nil)
+	if err := ack.write(buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	got := &acknowledgement{format: AckFormatBitmask}
+	if err := got.read(buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	sort.Slice(got.packets, func(i, j int) bool { return got.packets[i] < got.packets[j] })
+	want := []uint32{5, 8, 10}
+	if len(got.packets) != len(want) {
+		t.Fatalf("packets = %v, want %v", got.packets, want)
+	}
+	for i, seq := range want {
+		if got.packets[i] != seq {
+			t.Fatalf("packets = %v, want %v", got.packets, want)
+		}
+	}
+}
+
+// TestAcknowledgementEmptyRoundTrip verifies that an acknowledgement with no packets round-trips correctly
+// for both AckFormatRange and AckFormatBitmask: write always encodes it as a single zero byte, and read must
+// recognise that encoding before dispatching on format, since readBitmask would otherwise try to read 7
+// bytes that were never written.
+func TestAcknowledgementEmptyRoundTrip(t *testing.T) {
+	for _, format := range []AckFormat{AckFormatRange, AckFormatBitmask} {
+		buf := bytes.NewBuffer(nil)
+		if err := (&acknowledgement{format: format}).write(buf); err != nil {
+			t.Fatalf("format %v: write: %v", format, err)
+		}
+		if buf.Len() != 1 {
+			t.Fatalf("format %v: wrote %d bytes for an empty ack, want 1", format, buf.Len())
+		}
+
+		got := &acknowledgement{format: format}
+		if err := got.read(buf); err != nil {
+			t.Fatalf("format %v: read: %v", format, err)
+		}
+		if len(got.packets) != 0 {
+			t.Fatalf("format %v: packets = %v, want none", format, got.packets)
+		}
+	}
+}
+
+// TestAcknowledgementWriteBitmaskEmptyPanicRegression is a regression test for writeBitmask being reachable
+// with an empty ack.packets before write's own empty-slice guard ran.
+func TestAcknowledgementWriteBitmaskEmptyPanicRegression(t *testing.T) {
+	ack := &acknowledgement{format: AckFormatBitmask}
+	if err := ack.write(bytes.NewBuffer(nil)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package raknet
+
+import (
+	"container/list"
+	"time"
+)
+
+// defaultMinReoWnd is the default minimum reordering window used by RACK-based loss detection, as
+// recommended by RFC 8985 section 7.2.
+const defaultMinReoWnd = time.Millisecond
+
+// rackElem is an opaque handle into a rackLoss's transmission-ordered list, held by an inFlightPacket so it
+// can be relocated to the tail in O(1) time when the packet is retransmitted.
+type rackElem = list.Element
+
+// rackLoss implements RACK (Recent ACKnowledgment, RFC 8985) loss detection. It keeps every in-flight
+// packet in a list ordered by its most recent (re)transmission time, and considers any packet whose
+// transmission time is more than reoWnd older than the most recently acknowledged packet's to be lost,
+// without waiting for that packet's own retransmission timeout.
+type rackLoss struct {
+	minReoWnd time.Duration
+	reoWnd    time.Duration
+
+	// xmitTS is the send time of the most recently transmitted packet that has been acknowledged so far.
+	xmitTS time.Time
+	// endSeq is the sequence number of the packet xmitTS was recorded for.
+	endSeq uint32
+
+	// order holds every in-flight *inFlightPacket, ordered by ascending (re)transmission time. The most
+	// recently (re)sent packet is always at the back.
+	order *list.List
+}
+
+// newRackLoss returns a rackLoss detector using minReoWnd as the lower bound for its reordering window.
+func newRackLoss(minReoWnd time.Duration) *rackLoss {
+	return &rackLoss{minReoWnd: minReoWnd, reoWnd: minReoWnd, order: list.New()}
+}
+
+// add records ifp, sent at sentAt, at the tail of the transmission-ordered list.
+func (r *rackLoss) add(ifp *inFlightPacket, sentAt time.Time) *rackElem {
+	return r.order.PushBack(ifp)
+}
+
+// moveToTail relocates ifp to the tail of the transmission-ordered list after it has been retransmitted at
+// sentAt, preserving the invariant that the list stays ordered by transmission time.
+func (r *rackLoss) moveToTail(ifp *inFlightPacket, sentAt time.Time) {
+	if ifp.rackElem != nil {
+		r.order.MoveToBack(ifp.rackElem)
+	}
+}
+
+// onAck updates RACK.xmit_ts and RACK.end_seq from an acknowledged packet, if it is the most recently sent
+// packet acknowledged so far, and removes it from the transmission-ordered list.
+func (r *rackLoss) onAck(ifp *inFlightPacket, now time.Time) {
+	if ifp.sentAt.After(r.xmitTS) {
+		r.xmitTS = ifp.sentAt
+		r.endSeq = ifp.seq
+	}
+	if ifp.rackElem != nil {
+		r.order.Remove(ifp.rackElem)
+		ifp.rackElem = nil
+	}
+}
+
+// updateReoWnd recomputes the reordering window from the connection's smoothed RTT, per RFC 8985: a
+// quarter of the smoothed RTT, bounded below by minReoWnd.
+func (r *rackLoss) updateReoWnd(srtt time.Duration) {
+	wnd := srtt / 4
+	if wnd < r.minReoWnd {
+		wnd = r.minReoWnd
+	}
+	r.reoWnd = wnd
+}
+
+// detectLosses returns every in-flight packet whose most recent transmission time is more than reoWnd older
+// than RACK.xmit_ts, declaring them lost so that they may be retransmitted immediately instead of waiting
+// for their individual retransmission timeout.
+func (r *rackLoss) detectLosses(now time.Time) []*inFlightPacket {
+	if r.xmitTS.IsZero() {
+		return nil
+	}
+	var lost []*inFlightPacket
+	for e := r.order.Front(); e != nil; {
+		next := e.Next()
+		ifp := e.Value.(*inFlightPacket)
+		if ifp.seq != r.endSeq && r.xmitTS.Sub(ifp.sentAt) > r.reoWnd {
+			lost = append(lost, ifp)
+		}
+		e = next
+	}
+	return lost
+}
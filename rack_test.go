@@ -0,0 +1,74 @@
+package raknet
+
+import (
+	"testing"
+	"time"
+)
+
+// spyCongestionControl is a minimal CongestionControl used by tests to observe how many times a hook fires,
+// without exercising any actual congestion control behaviour.
+type spyCongestionControl struct {
+	sendWindow  int
+	packetsSent map[uint32]int
+}
+
+func newSpyCongestionControl(sendWindow int) *spyCongestionControl {
+	return &spyCongestionControl{sendWindow: sendWindow, packetsSent: make(map[uint32]int)}
+}
+
+func (s *spyCongestionControl) OnPacketSent(seq uint32, bytes int)  { s.packetsSent[seq]++ }
+func (s *spyCongestionControl) OnAck(seq uint32, rtt time.Duration) {}
+func (s *spyCongestionControl) OnDupAckThresholdReached(seq uint32) {}
+func (s *spyCongestionControl) OnRTO(seq uint32)                    {}
+func (s *spyCongestionControl) SendWindow() int                     { return s.sendWindow }
+
+// TestRackLossDetectLosses verifies that a packet sent long enough before the most recently acknowledged
+// one is reported lost, while the acknowledged packet itself is not.
+func TestRackLossDetectLosses(t *testing.T) {
+	r := newRackLoss(time.Millisecond)
+	now := time.Now()
+
+	old := &inFlightPacket{seq: 1, sentAt: now.Add(-10 * time.Millisecond)}
+	old.rackElem = r.add(old, old.sentAt)
+	acked := &inFlightPacket{seq: 2, sentAt: now}
+	acked.rackElem = r.add(acked, acked.sentAt)
+
+	r.onAck(acked, now)
+	r.updateReoWnd(4 * time.Millisecond) // reoWnd = srtt/4 = 1ms
+
+	lost := r.detectLosses(now)
+	if len(lost) != 1 || lost[0].seq != old.seq {
+		t.Fatalf("detectLosses = %v, want just seq %d", lost, old.seq)
+	}
+}
+
+// TestHandleAcknowledgementRACKLossRetransmittedOnce verifies that a packet RACK detects as lost is
+// retransmitted only once per handleAcknowledgement call: the RACK retransmit path must mark the packet in
+// the SACK scoreboard so that the recoverLosses pass triggered right afterwards doesn't see it as an
+// eligible hole and resend it a second time.
+func TestHandleAcknowledgementRACKLossRetransmittedOnce(t *testing.T) {
+	cc := newSpyCongestionControl(10)
+	conn := newConn()
+	conn.cc = cc
+	conn.EnableRACK(time.Millisecond)
+
+	now := time.Now()
+	lostSentAt := now.Add(-10 * time.Millisecond)
+	lost := &packet{reliability: reliabilityReliable, content: []byte("x"), messageIndex: 1}
+	conn.inFlight[1] = &inFlightPacket{pk: lost, seq: 1, sentAt: lostSentAt}
+	conn.inFlight[1].rackElem = conn.rack.add(conn.inFlight[1], lostSentAt)
+
+	acked := &packet{reliability: reliabilityReliable, content: []byte("y"), messageIndex: 2}
+	conn.inFlight[2] = &inFlightPacket{pk: acked, seq: 2, sentAt: now}
+	conn.inFlight[2].rackElem = conn.rack.add(conn.inFlight[2], now)
+
+	conn.handleAcknowledgement(&acknowledgement{packets: []uint32{2}})
+
+	if n := cc.packetsSent[1]; n != 1 {
+		t.Fatalf("packet 1 retransmitted %d times in a single handleAcknowledgement call, want exactly 1", n)
+	}
+	if !conn.sack.highRxtSet || conn.sack.highRxt != lost.messageIndex {
+		t.Fatalf("sack.highRxt = %v (set=%v), want %v (set=true) after RACK retransmits a hole",
+			conn.sack.highRxt, conn.sack.highRxtSet, lost.messageIndex)
+	}
+}
@@ -0,0 +1,33 @@
+package raknet
+
+// Reliability is the reliability with which a packet is sent through Conn.WriteOrdered or Conn.WriteDatagram.
+// Its values correspond directly to RakNet's wire-level reliability types.
+type Reliability byte
+
+const (
+	// ReliabilityUnreliable means that the packet sent could arrive out of order, be duplicated, or just not
+	// arrive at all. It is usually used for high frequency packets of which the order does not matter.
+	ReliabilityUnreliable Reliability = iota
+	// ReliabilityUnreliableSequenced means that the packet sent could be duplicated or not arrive at all, but
+	// ensures that it is always handled in the right order.
+	ReliabilityUnreliableSequenced
+	// ReliabilityReliable means that the packet sent could not arrive, or arrive out of order, but ensures
+	// that the packet is not duplicated.
+	ReliabilityReliable
+	// ReliabilityReliableOrdered means that every packet sent arrives, arrives in the right order and is not
+	// duplicated.
+	ReliabilityReliableOrdered
+	// ReliabilityReliableSequenced means that the packet sent could not arrive, but ensures that the packet
+	// will be in the right order and not be duplicated.
+	ReliabilityReliableSequenced
+	// ReliabilityUnreliableWithAck means that the packet sent could arrive out of order, be duplicated or
+	// just not arrive at all. The client will send an acknowledgement if it got the packet.
+	ReliabilityUnreliableWithAck
+	// ReliabilityReliableWithAck means that every packet sent arrives, arrives in the right order and is not
+	// duplicated. The client will send an acknowledgement if it got the packet.
+	ReliabilityReliableWithAck
+	// ReliabilityReliableOrderedWithAck means that the packet sent could not arrive, but ensures that the
+	// packet will be in the right order and not be duplicated. The client will send an acknowledgement if it
+	// got the packet.
+	ReliabilityReliableOrderedWithAck
+)
@@ -0,0 +1,90 @@
+package raknet
+
+import (
+	"math"
+	"time"
+)
+
+// initialCwnd is the number of datagrams NewReno allows in flight before any acknowledgement has been
+// received, as recommended for small, latency-sensitive connections such as those RakNet is typically used
+// for.
+const initialCwnd = 10
+
+// renoState describes which phase of the NewReno congestion avoidance algorithm a connection is in.
+type renoState uint8
+
+const (
+	renoSlowStart renoState = iota
+	renoCongestionAvoidance
+	renoFastRecovery
+)
+
+// newReno is the default CongestionControl implementation, modelled after the NewReno algorithm: it starts
+// in slow start, switches to linear congestion avoidance once the slow start threshold is crossed, and
+// performs a fast retransmit/fast recovery once three duplicate acknowledgements are seen for the same
+// packet.
+type newReno struct {
+	cwnd, ssthresh float64
+	dupAcks        int
+	state          renoState
+}
+
+// newNewRenoCongestionControl returns a new NewReno CongestionControl, used as the default if no other
+// CongestionControl is registered or selected.
+func newNewRenoCongestionControl() CongestionControl {
+	return &newReno{cwnd: initialCwnd, ssthresh: math.MaxFloat64, state: renoSlowStart}
+}
+
+// OnPacketSent does nothing: NewReno only reacts to acknowledgements, duplicate-ACK signals and timeouts, so
+// it needs no bookkeeping at the moment a packet is sent.
+func (reno *newReno) OnPacketSent(seq uint32, bytes int) {}
+
+// OnAck grows the congestion window on a new acknowledgement: by one full segment per acknowledgement while
+// in slow start, and by one segment per window while in congestion avoidance, crossing from the former to the
+// latter once cwnd reaches ssthresh. An acknowledgement also ends fast recovery, resuming congestion
+// avoidance from the reduced window set by OnDupAckThresholdReached.
+func (reno *newReno) OnAck(seq uint32, rtt time.Duration) {
+	reno.dupAcks = 0
+	switch reno.state {
+	case renoSlowStart:
+		reno.cwnd++
+		if reno.cwnd >= reno.ssthresh {
+			reno.state = renoCongestionAvoidance
+		}
+	case renoCongestionAvoidance:
+		reno.cwnd += 1 / reno.cwnd
+	case renoFastRecovery:
+		reno.cwnd = reno.ssthresh
+		reno.state = renoCongestionAvoidance
+	}
+}
+
+// OnDupAckThresholdReached performs NewReno's fast retransmit/fast recovery: it halves the congestion
+// window, subject to a floor of 2, remembers the result as the new slow start threshold, and enters fast
+// recovery, unless a fast recovery triggered by an earlier packet lost in the same window is already in
+// progress.
+func (reno *newReno) OnDupAckThresholdReached(seq uint32) {
+	if reno.state == renoFastRecovery {
+		// Already recovering from a loss episode: don't halve the window again for a packet lost in the
+		// same window.
+		return
+	}
+	reno.ssthresh = math.Max(reno.cwnd/2, 2)
+	reno.cwnd = reno.ssthresh
+	reno.state = renoFastRecovery
+}
+
+// OnRTO performs NewReno's response to a retransmission timeout: since a timeout means dup-ACK based
+// recovery didn't catch the loss, it is treated as a more severe congestion signal than
+// OnDupAckThresholdReached, setting the slow start threshold to half the congestion window (with a floor of
+// 2) and dropping the congestion window all the way back to 1 before returning to slow start.
+func (reno *newReno) OnRTO(seq uint32) {
+	reno.ssthresh = math.Max(reno.cwnd/2, 2)
+	reno.cwnd = 1
+	reno.state = renoSlowStart
+}
+
+// SendWindow returns the current congestion window, rounded down to the nearest whole datagram.
+func (reno *newReno) SendWindow() int {
+	return int(reno.cwnd)
+}
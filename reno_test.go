@@ -0,0 +1,55 @@
+package raknet
+
+import (
+	"testing"
+	"time"
+)
+
+// TestNewRenoSlowStartToCongestionAvoidance verifies that newReno grows its window by one full segment per
+// acknowledgement while in slow start, and switches to congestion avoidance once cwnd reaches ssthresh.
+func TestNewRenoSlowStartToCongestionAvoidance(t *testing.T) {
+	reno := newNewRenoCongestionControl().(*newReno)
+	reno.ssthresh = reno.cwnd + 2
+
+	for i := 0; i < 2; i++ {
+		reno.OnAck(uint32(i), time.Millisecond)
+	}
+	if reno.state != renoCongestionAvoidance {
+		t.Fatalf("state = %v, want renoCongestionAvoidance once cwnd reaches ssthresh", reno.state)
+	}
+}
+
+// TestNewRenoDupAckThresholdReachedHalvesWindow verifies that OnDupAckThresholdReached performs NewReno's
+// fast retransmit/fast recovery: halving cwnd (with a floor of 2), remembering it as ssthresh, and entering
+// fast recovery, but only once per loss episode.
+func TestNewRenoDupAckThresholdReachedHalvesWindow(t *testing.T) {
+	reno := newNewRenoCongestionControl().(*newReno)
+	reno.cwnd = 20
+
+	reno.OnDupAckThresholdReached(1)
+	if reno.cwnd != 10 || reno.ssthresh != 10 || reno.state != renoFastRecovery {
+		t.Fatalf("after first OnDupAckThresholdReached: cwnd=%v ssthresh=%v state=%v, want 10/10/renoFastRecovery",
+			reno.cwnd, reno.ssthresh, reno.state)
+	}
+
+	// A second loss detected within the same fast recovery episode must not halve the window again.
+	reno.OnDupAckThresholdReached(2)
+	if reno.cwnd != 10 {
+		t.Fatalf("cwnd = %v after second OnDupAckThresholdReached in the same episode, want unchanged 10", reno.cwnd)
+	}
+}
+
+// TestNewRenoOnRTOResetsToSlowStart verifies that a retransmission timeout drops the congestion window all
+// the way back to 1 and returns to slow start, rather than the milder halving OnDupAckThresholdReached does.
+func TestNewRenoOnRTOResetsToSlowStart(t *testing.T) {
+	reno := newNewRenoCongestionControl().(*newReno)
+	reno.cwnd = 20
+
+	reno.OnRTO(1)
+	if reno.cwnd != 1 || reno.ssthresh != 10 || reno.state != renoSlowStart {
+		t.Fatalf("after OnRTO: cwnd=%v ssthresh=%v state=%v, want 1/10/renoSlowStart", reno.cwnd, reno.ssthresh, reno.state)
+	}
+	if reno.SendWindow() != 1 {
+		t.Fatalf("SendWindow() = %v, want 1", reno.SendWindow())
+	}
+}
@@ -0,0 +1,99 @@
+package raknet
+
+import (
+	"container/heap"
+	"errors"
+)
+
+// maxOrderingChannels is the number of independent ordering channels RakNet supports, per the spec (0-31).
+const maxOrderingChannels = 32
+
+// defaultOrderedHighWaterMark is the default number of out-of-order packets a single ordering channel's
+// streamBuffer may hold before it starts rejecting further ones, so that a single stalled channel cannot
+// exhaust memory buffering packets that arrived ahead of a missing one.
+const defaultOrderedHighWaterMark = 512
+
+// errOrderedChannelFull is returned when a streamBuffer already holds as many out-of-order packets as its
+// high-water mark allows.
+var errOrderedChannelFull = errors.New("raknet: ordering channel buffer full")
+
+// errNoOrderedPacket is returned by Conn.ReadOrdered when no payload is currently ready to be delivered on
+// the requested ordering channel.
+var errNoOrderedPacket = errors.New("raknet: no ordered packet ready")
+
+// orderedItem is a single out-of-order packet held by a streamBuffer's heap, keyed by orderIndex.
+type orderedItem struct {
+	orderIndex uint32
+	content    []byte
+}
+
+// orderedHeap is a container/heap.Interface over orderedItem, ordered by ascending orderIndex.
+type orderedHeap []orderedItem
+
+func (h orderedHeap) Len() int            { return len(h) }
+func (h orderedHeap) Less(i, j int) bool  { return h[i].orderIndex < h[j].orderIndex }
+func (h orderedHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *orderedHeap) Push(x interface{}) { *h = append(*h, x.(orderedItem)) }
+func (h *orderedHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// streamBuffer reassembles a single ordering channel's reliably-ordered packets into the order they were
+// sent in. Packets that arrive ahead of the next expected orderIndex are held in a min-heap keyed by
+// orderIndex until the packets that precede them arrive, at which point they, and any run of packets that
+// follow them, are released together.
+type streamBuffer struct {
+	// expected is the orderIndex of the next packet that may be delivered.
+	expected uint32
+	// heap holds packets that arrived ahead of expected, keyed by orderIndex.
+	heap orderedHeap
+	// buffered holds the orderIndex of every packet currently sitting in heap, so that a retransmission of a
+	// packet already buffered there can be recognised as a duplicate instead of being queued a second time.
+	buffered map[uint32]struct{}
+	// highWaterMark bounds the number of packets heap may hold before push starts returning
+	// errOrderedChannelFull.
+	highWaterMark int
+}
+
+// newStreamBuffer returns a streamBuffer that rejects packets once it is already holding highWaterMark
+// out-of-order packets.
+func newStreamBuffer(highWaterMark int) *streamBuffer {
+	return &streamBuffer{highWaterMark: highWaterMark, buffered: make(map[uint32]struct{})}
+}
+
+// push records a packet received with orderIndex, returning every payload that has become deliverable as a
+// result, in the order it was originally sent. A packet is a duplicate, and is silently dropped, either if
+// its orderIndex is below one already delivered, or if it is already sitting in heap awaiting an earlier gap
+// to fill - the latter is routine once the retransmission paths introduced alongside this buffer are taken
+// into account, since the peer may re-send a packet it has already received but not yet been able to
+// deliver.
+func (s *streamBuffer) push(orderIndex uint32, content []byte) ([][]byte, error) {
+	if orderIndex < s.expected {
+		return nil, nil
+	}
+	if orderIndex != s.expected {
+		if _, ok := s.buffered[orderIndex]; ok {
+			return nil, nil
+		}
+		if len(s.heap) >= s.highWaterMark {
+			return nil, errOrderedChannelFull
+		}
+		heap.Push(&s.heap, orderedItem{orderIndex: orderIndex, content: content})
+		s.buffered[orderIndex] = struct{}{}
+		return nil, nil
+	}
+
+	ready := [][]byte{content}
+	s.expected++
+	for len(s.heap) > 0 && s.heap[0].orderIndex == s.expected {
+		item := heap.Pop(&s.heap).(orderedItem)
+		delete(s.buffered, item.orderIndex)
+		ready = append(ready, item.content)
+		s.expected++
+	}
+	return ready, nil
+}
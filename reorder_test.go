@@ -0,0 +1,91 @@
+package raknet
+
+import "testing"
+
+// TestStreamBufferOutOfOrderDelivery verifies that packets arriving ahead of the expected orderIndex are held
+// back, and released together, in order, once the gap is filled.
+func TestStreamBufferOutOfOrderDelivery(t *testing.T) {
+	s := newStreamBuffer(8)
+
+	if ready, err := s.push(2, []byte("c")); err != nil || len(ready) != 0 {
+		t.Fatalf("push(2) = %v, %v, want no ready payloads", ready, err)
+	}
+	if ready, err := s.push(1, []byte("b")); err != nil || len(ready) != 0 {
+		t.Fatalf("push(1) = %v, %v, want no ready payloads", ready, err)
+	}
+
+	ready, err := s.push(0, []byte("a"))
+	if err != nil {
+		t.Fatalf("push(0): %v", err)
+	}
+	want := []string{"a", "b", "c"}
+	if len(ready) != len(want) {
+		t.Fatalf("ready = %v, want %v", ready, want)
+	}
+	for i, w := range want {
+		if string(ready[i]) != w {
+			t.Fatalf("ready = %v, want %v", ready, want)
+		}
+	}
+}
+
+// TestStreamBufferDropsDuplicateAlreadyDelivered verifies that a retransmission of a packet whose orderIndex
+// has already been delivered is silently dropped rather than re-delivered.
+func TestStreamBufferDropsDuplicateAlreadyDelivered(t *testing.T) {
+	s := newStreamBuffer(8)
+
+	if _, err := s.push(0, []byte("a")); err != nil {
+		t.Fatalf("push(0): %v", err)
+	}
+	ready, err := s.push(0, []byte("a"))
+	if err != nil {
+		t.Fatalf("push(0) again: %v", err)
+	}
+	if len(ready) != 0 {
+		t.Fatalf("ready = %v, want none for a duplicate of an already-delivered packet", ready)
+	}
+}
+
+// TestStreamBufferDropsDuplicateAlreadyBuffered verifies that a retransmission of a packet already sitting in
+// the heap awaiting an earlier gap is dropped rather than queued a second time, which would otherwise
+// deliver its payload twice once the gap is filled.
+func TestStreamBufferDropsDuplicateAlreadyBuffered(t *testing.T) {
+	s := newStreamBuffer(8)
+
+	if _, err := s.push(1, []byte("b")); err != nil {
+		t.Fatalf("push(1): %v", err)
+	}
+	if _, err := s.push(1, []byte("b")); err != nil {
+		t.Fatalf("push(1) again: %v", err)
+	}
+
+	ready, err := s.push(0, []byte("a"))
+	if err != nil {
+		t.Fatalf("push(0): %v", err)
+	}
+	want := []string{"a", "b"}
+	if len(ready) != len(want) {
+		t.Fatalf("ready = %v, want %v (the duplicate buffered push must not deliver \"b\" twice)", ready, want)
+	}
+	for i, w := range want {
+		if string(ready[i]) != w {
+			t.Fatalf("ready = %v, want %v", ready, want)
+		}
+	}
+}
+
+// TestStreamBufferRejectsPastHighWaterMark verifies that push refuses to buffer more out-of-order packets
+// than highWaterMark, returning errOrderedChannelFull rather than growing the heap without bound.
+func TestStreamBufferRejectsPastHighWaterMark(t *testing.T) {
+	s := newStreamBuffer(2)
+
+	if _, err := s.push(1, []byte("b")); err != nil {
+		t.Fatalf("push(1): %v", err)
+	}
+	if _, err := s.push(2, []byte("c")); err != nil {
+		t.Fatalf("push(2): %v", err)
+	}
+	if _, err := s.push(3, []byte("d")); err != errOrderedChannelFull {
+		t.Fatalf("push(3) err = %v, want errOrderedChannelFull", err)
+	}
+}
@@ -0,0 +1,121 @@
+package raknet
+
+import (
+	"sort"
+	"time"
+)
+
+// sackRecovery implements RFC 6675-style scoreboard loss recovery on top of the acknowledgement stream.
+// Rather than retransmitting every unacknowledged packet below a newly acknowledged one as soon as it's
+// reported missing, it retransmits each at most once per loss episode via HighRxt, and keeps the ACK clock
+// going with an occasional rescue retransmission via RescueRxt when the sender has no new data to send.
+type sackRecovery struct {
+	// highestAcked is the highest sequence number acknowledged so far.
+	highestAcked uint32
+	// highestAckedSet reports whether highestAcked holds a valid value yet.
+	highestAckedSet bool
+	// lastAckAdvance is the last time an acknowledgement advanced highestAcked.
+	lastAckAdvance time.Time
+
+	// highRxt is the highest messageIndex retransmitted so far during the current loss episode. Only
+	// packets with a messageIndex greater than highRxt are retransmitted, so a packet already retransmitted
+	// this episode isn't sent again purely because it remains unacknowledged.
+	highRxt uint32
+	// highRxtSet reports whether a loss episode is in progress, i.e. whether highRxt holds a valid value.
+	highRxtSet bool
+
+	// rescueRxt is the highest seq optimistically retransmitted to keep the ACK clock going while no new
+	// data was available to send.
+	rescueRxt uint32
+	// lastRescueAt is the last time a rescue retransmission was sent.
+	lastRescueAt time.Time
+}
+
+// onAck records that seq was acknowledged at now, returning whether it advanced highestAcked.
+func (s *sackRecovery) onAck(seq uint32, now time.Time) bool {
+	if s.highestAckedSet && seq <= s.highestAcked {
+		return false
+	}
+	s.highestAcked = seq
+	s.highestAckedSet = true
+	s.lastAckAdvance = now
+	return true
+}
+
+// eligible reports whether ifp may be retransmitted under the current loss episode: it must not already
+// have been retransmitted this episode. This relies on every reliable packet being given a distinct,
+// monotonically increasing messageIndex by the send path (see Conn.WriteOrdered) - without that, every
+// packet would compare equal and only the first hole of any episode would ever be retransmitted.
+func (s *sackRecovery) eligible(ifp *inFlightPacket) bool {
+	return !s.highRxtSet || ifp.pk.messageIndex > s.highRxt
+}
+
+// markRetransmitted records that ifp was just retransmitted as part of the current loss episode, starting
+// one if none was already in progress. highRxt only ever moves forward: callers may observe holes out of
+// messageIndex order (conn.inFlight is a map, and updateDupAcks and recoverLosses can each retransmit a
+// hole in the same loss episode), and letting a lower messageIndex regress highRxt would make an
+// already-retransmitted higher hole eligible again while wrongly marking a not-yet-retransmitted one as
+// covered.
+func (s *sackRecovery) markRetransmitted(ifp *inFlightPacket) {
+	if !s.highRxtSet || ifp.pk.messageIndex > s.highRxt {
+		s.highRxt = ifp.pk.messageIndex
+	}
+	s.highRxtSet = true
+}
+
+// recoverLosses implements the scoreboard half of RFC 6675: packets below the highest acknowledged sequence
+// number that are still outstanding are holes in the scoreboard. Once the number of packets in flight drops
+// below the send window, those holes are presumed lost and retransmitted, each at most once per loss
+// episode. If there turn out to be no holes left, the loss episode is considered over.
+func (conn *Conn) recoverLosses(now time.Time) {
+	if !conn.sack.highestAckedSet {
+		return
+	}
+	var holes []*inFlightPacket
+	for seq, ifp := range conn.inFlight {
+		if seq < conn.sack.highestAcked {
+			holes = append(holes, ifp)
+		}
+	}
+	if len(holes) == 0 {
+		conn.sack.highRxtSet = false
+		conn.maybeRescueRetransmit(now)
+		return
+	}
+	if len(conn.inFlight) >= conn.cc.SendWindow() {
+		// The pipe is still full: wait for more room before presuming these packets lost.
+		return
+	}
+	sort.Slice(holes, func(i, j int) bool { return holes[i].seq < holes[j].seq })
+	for _, ifp := range holes {
+		if !conn.sack.eligible(ifp) {
+			continue
+		}
+		conn.retransmitAndMark(ifp)
+	}
+	conn.maybeRescueRetransmit(now)
+}
+
+// maybeRescueRetransmit sends a single rescue retransmission of the highest unacknowledged packet if the
+// sender has no new data queued and a full RTT has passed without the ACK clock advancing, so that the
+// connection doesn't stall waiting for an RTO when the tail of the window is lost.
+func (conn *Conn) maybeRescueRetransmit(now time.Time) {
+	if len(conn.pending) != 0 || len(conn.inFlight) == 0 || conn.srtt == 0 {
+		return
+	}
+	if now.Sub(conn.sack.lastAckAdvance) < conn.srtt {
+		return
+	}
+	if !conn.sack.lastRescueAt.IsZero() && now.Sub(conn.sack.lastRescueAt) < conn.srtt {
+		return
+	}
+	var target *inFlightPacket
+	for seq, ifp := range conn.inFlight {
+		if target == nil || seq > target.seq {
+			target = ifp
+		}
+	}
+	conn.retransmit(target)
+	conn.sack.rescueRxt = target.seq
+	conn.sack.lastRescueAt = now
+}
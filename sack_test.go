@@ -0,0 +1,95 @@
+package raknet
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRecoverLossesRetransmitsHolesOnce verifies the scoreboard half of RFC 6675: once the highest
+// acknowledged sequence number advances past some still-outstanding packets and the pipe has room, those
+// holes are retransmitted, but a second recoverLosses pass within the same loss episode must not retransmit
+// them again thanks to HighRxt.
+func TestRecoverLossesRetransmitsHolesOnce(t *testing.T) {
+	cc := newSpyCongestionControl(10)
+	conn := newConn()
+	conn.cc = cc
+
+	hole := &packet{reliability: reliabilityReliable, content: []byte("x"), messageIndex: 1}
+	conn.inFlight[1] = &inFlightPacket{pk: hole, seq: 1, sentAt: time.Now()}
+
+	conn.sack.onAck(5, time.Now())
+
+	conn.recoverLosses(time.Now())
+	if n := cc.packetsSent[1]; n != 1 {
+		t.Fatalf("packet 1 retransmitted %d times on first recoverLosses pass, want 1", n)
+	}
+	if !conn.sack.highRxtSet || conn.sack.highRxt != hole.messageIndex {
+		t.Fatalf("sack.highRxt = %v (set=%v), want %v (set=true) after retransmitting a hole",
+			conn.sack.highRxt, conn.sack.highRxtSet, hole.messageIndex)
+	}
+
+	// The hole is still in conn.inFlight (it hasn't been acknowledged), so a second pass within the same
+	// episode must see it as ineligible rather than retransmitting it again.
+	conn.recoverLosses(time.Now())
+	if n := cc.packetsSent[1]; n != 1 {
+		t.Fatalf("packet 1 retransmitted %d times after a second recoverLosses pass in the same episode, want unchanged 1", n)
+	}
+}
+
+// TestRecoverLossesEndsEpisodeWhenNoHolesRemain verifies that once every packet below highestAcked has been
+// acknowledged, recoverLosses clears the in-progress loss episode so a future hole can be retransmitted
+// again from a clean HighRxt state.
+func TestRecoverLossesEndsEpisodeWhenNoHolesRemain(t *testing.T) {
+	conn := newConn()
+	conn.cc = newSpyCongestionControl(10)
+	conn.sack.highRxt = 1
+	conn.sack.highRxtSet = true
+	conn.sack.onAck(5, time.Now())
+
+	conn.recoverLosses(time.Now())
+
+	if conn.sack.highRxtSet {
+		t.Fatalf("sack.highRxtSet = true after a recoverLosses pass with no outstanding holes, want false")
+	}
+}
+
+// TestUpdateDupAcksDoesNotRegressHighRxt verifies that updateDupAcks respects HighRxt the same way
+// recoverLosses does: conn.inFlight is a map, so a packet with a lower messageIndex than one already
+// retransmitted this episode can be the one updateDupAcks happens to visit and cross dupAckThreshold for.
+// Its messageIndex is already covered by HighRxt, so it must be skipped rather than retransmitted, and
+// HighRxt must stay at the higher messageIndex so that a future recoverLosses pass does not resend that
+// higher hole a second time within the same loss episode.
+func TestUpdateDupAcksDoesNotRegressHighRxt(t *testing.T) {
+	cc := newSpyCongestionControl(10)
+	conn := newConn()
+	conn.cc = cc
+
+	lowHole := &packet{reliability: reliabilityReliable, content: []byte("x"), messageIndex: 1}
+	conn.inFlight[1] = &inFlightPacket{pk: lowHole, seq: 1, sentAt: time.Now()}
+	highHole := &packet{reliability: reliabilityReliable, content: []byte("y"), messageIndex: 10}
+	conn.inFlight[2] = &inFlightPacket{pk: highHole, seq: 2, sentAt: time.Now()}
+
+	conn.sack.onAck(5, time.Now())
+	conn.recoverLosses(time.Now())
+	if n := cc.packetsSent[2]; n != 1 {
+		t.Fatalf("packet 2 (messageIndex %d) retransmitted %d times on first recoverLosses pass, want 1", highHole.messageIndex, n)
+	}
+	if conn.sack.highRxt != highHole.messageIndex {
+		t.Fatalf("sack.highRxt = %v after recoverLosses, want %v", conn.sack.highRxt, highHole.messageIndex)
+	}
+
+	// Drive packet 1's dup-ACK counter past the threshold without touching packet 2's.
+	conn.inFlight[1].dupAcks = dupAckThreshold - 1
+	conn.updateDupAcks()
+
+	if conn.sack.highRxt != highHole.messageIndex {
+		t.Fatalf("sack.highRxt = %v after updateDupAcks retransmitted a lower messageIndex, want unchanged %v (HighRxt must not regress)",
+			conn.sack.highRxt, highHole.messageIndex)
+	}
+
+	// A later recoverLosses pass must not resend packet 2 again within the same episode.
+	conn.recoverLosses(time.Now())
+	if n := cc.packetsSent[2]; n != 1 {
+		t.Fatalf("packet 2 retransmitted %d times after HighRxt would have regressed, want unchanged 1", n)
+	}
+}